@@ -0,0 +1,53 @@
+// Command seaweedfs-csi-controller runs the CSI controller service. It only
+// needs filer credentials, not the mount toolchain, so it can run as a
+// Deployment with a much smaller RBAC surface and image than the node
+// DaemonSet.
+package main
+
+import (
+	"flag"
+
+	"github.com/Ruakij/seaweedfs-csi-driver/pkg/controller"
+	"github.com/Ruakij/seaweedfs-csi-driver/pkg/driver"
+	"github.com/Ruakij/seaweedfs-csi-driver/pkg/identity"
+	"github.com/chrislusf/seaweedfs/weed/util/log"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+)
+
+var (
+	endpoint     = flag.String("endpoint", "unix:///csi/csi.sock", "CSI endpoint")
+	nodeID       = flag.String("nodeid", "", "node id (unused by the controller, kept for symmetry with the node binary)")
+	filerAddress = flag.String("filer", "", "SeaweedFS filer gRPC address, e.g. seaweedfs-filer:18888")
+	version      = "dev"
+)
+
+func main() {
+	flag.Parse()
+
+	if *filerAddress == "" {
+		log.Fatalf("-filer is required")
+	}
+
+	d := driver.NewCSIDriver(*nodeID, *endpoint, *filerAddress, version)
+	d.AddControllerServiceCapabilities([]csi.ControllerServiceCapability_RPC_Type{
+		csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME,
+		csi.ControllerServiceCapability_RPC_EXPAND_VOLUME,
+		csi.ControllerServiceCapability_RPC_CREATE_DELETE_SNAPSHOT,
+		csi.ControllerServiceCapability_RPC_LIST_SNAPSHOTS,
+		csi.ControllerServiceCapability_RPC_GET_CAPACITY,
+		csi.ControllerServiceCapability_RPC_LIST_VOLUMES,
+	})
+	d.AddVolumeCapabilityAccessModes([]csi.VolumeCapability_AccessMode_Mode{
+		csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+		csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY,
+		csi.VolumeCapability_AccessMode_MULTI_NODE_SINGLE_WRITER,
+		csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+	})
+
+	ids := &identity.IdentityServer{Driver: d}
+	cs := &controller.ControllerServer{Driver: d}
+
+	s := &driver.NonBlockingGRPCServer{}
+	s.Start(*endpoint, ids, cs, nil)
+	s.Wait()
+}
@@ -0,0 +1,45 @@
+// Command seaweedfs-csi-node runs the CSI node service. It only needs the
+// `weed` mount toolchain, not filer credentials, keeping the DaemonSet image
+// small.
+package main
+
+import (
+	"flag"
+
+	"github.com/Ruakij/seaweedfs-csi-driver/pkg/driver"
+	"github.com/Ruakij/seaweedfs-csi-driver/pkg/identity"
+	"github.com/Ruakij/seaweedfs-csi-driver/pkg/node"
+	"github.com/chrislusf/seaweedfs/weed/util/log"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+)
+
+var (
+	endpoint     = flag.String("endpoint", "unix:///csi/csi.sock", "CSI endpoint")
+	nodeID       = flag.String("nodeid", "", "node id")
+	filerAddress = flag.String("filer", "", "SeaweedFS filer gRPC address, passed through to `weed mount`")
+	datacenter   = flag.String("datacenter", "", "this node's SeaweedFS datacenter, e.g. from a DownwardAPI-mounted label file")
+	rack         = flag.String("rack", "", "this node's SeaweedFS rack")
+	version      = "dev"
+)
+
+func main() {
+	flag.Parse()
+
+	if *nodeID == "" {
+		log.Fatalf("-nodeid is required")
+	}
+
+	d := driver.NewCSIDriver(*nodeID, *endpoint, *filerAddress, version)
+	d.Datacenter = *datacenter
+	d.Rack = *rack
+	d.AddNodeServiceCapabilities([]csi.NodeServiceCapability_RPC_Type{
+		csi.NodeServiceCapability_RPC_EXPAND_VOLUME,
+	})
+
+	ids := &identity.IdentityServer{Driver: d}
+	ns := &node.NodeServer{Driver: d}
+
+	s := &driver.NonBlockingGRPCServer{}
+	s.Start(*endpoint, ids, nil, ns)
+	s.Wait()
+}
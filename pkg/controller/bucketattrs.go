@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/Ruakij/seaweedfs-csi-driver/pkg/driver"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+)
+
+// ensureDir creates parentDir/name if it doesn't already exist. Unlike a
+// bucket directory, which CreateVolume only ever creates once per volumeId,
+// directories like /snapshots/<volumeId> are shared across repeated calls
+// (e.g. taking a second snapshot of the same volume), so callers must not
+// assume filer_pb.Mkdir tolerates an existing target.
+func ensureDir(csiDriver *driver.CSIDriver, parentDir, name string) error {
+	exists, err := filer_pb.Exists(csiDriver, parentDir, name, true)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	return filer_pb.Mkdir(csiDriver, parentDir, name, nil)
+}
+
+// setEntryExtended merges kv into the Extended attributes of the filer entry
+// at parentDir/name. It looks the entry up first so unrelated attributes
+// (and, for files, chunks) survive the update instead of being clobbered.
+func setEntryExtended(csiDriver *driver.CSIDriver, parentDir, name string, kv map[string][]byte) error {
+	return csiDriver.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+		lookup, err := client.LookupDirectoryEntry(context.Background(), &filer_pb.LookupDirectoryEntryRequest{
+			Directory: parentDir,
+			Name:      name,
+		})
+		if err != nil {
+			return err
+		}
+
+		entry := lookup.Entry
+		if entry.Extended == nil {
+			entry.Extended = make(map[string][]byte, len(kv))
+		}
+		for k, v := range kv {
+			entry.Extended[k] = v
+		}
+
+		_, err = client.UpdateEntry(context.Background(), &filer_pb.UpdateEntryRequest{
+			Directory: parentDir,
+			Entry:     entry,
+		})
+		return err
+	})
+}
@@ -0,0 +1,633 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ruakij/seaweedfs-csi-driver/pkg/driver"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util/log"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type ControllerServer struct {
+	Driver *driver.CSIDriver
+}
+
+var _ = csi.ControllerServer(&ControllerServer{})
+
+// allowedAccessModesParam lets a StorageClass restrict which of the
+// driver's advertised access modes it permits, e.g.
+// "allowedAccessModes: SINGLE_NODE_WRITER,MULTI_NODE_READER_ONLY".
+const allowedAccessModesParam = "allowedAccessModes"
+
+// bucketQuotaAttr records the most recently requested capacity on the bucket
+// directory entry. SeaweedFS has no filer_pb RPC for bucket quotas and
+// nothing in this driver reads the attribute back; it exists purely so an
+// operator inspecting the bucket's extended attributes (e.g. via
+// `weed shell fs.meta.cat`) can see the size the PVC was resized to. The
+// volume itself already grows without a hard limit, so ControllerExpandVolume
+// has nothing to enforce and NodeExpandVolume has nothing to propagate.
+const bucketQuotaAttr = "seaweedfs-csi/quota-bytes"
+
+func allowedAccessModes(driverVcap []*csi.VolumeCapability_AccessMode, params map[string]string) []*csi.VolumeCapability_AccessMode {
+	allowed, ok := params[allowedAccessModesParam]
+	if !ok || allowed == "" {
+		return driverVcap
+	}
+
+	allowedNames := make(map[string]bool)
+	for _, name := range strings.Split(allowed, ",") {
+		allowedNames[strings.TrimSpace(name)] = true
+	}
+
+	var filtered []*csi.VolumeCapability_AccessMode
+	for _, c := range driverVcap {
+		if allowedNames[c.GetMode().String()] {
+			filtered = append(filtered, c)
+		}
+	}
+	return filtered
+}
+
+func (cs *ControllerServer) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequest) (*csi.CreateVolumeResponse, error) {
+
+	volumeId := sanitizeVolumeId(req.GetName())
+
+	if err := cs.Driver.ValidateControllerServiceRequest(csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME); err != nil {
+		log.Tracef("invalid create volume req: %v", req)
+		return nil, err
+	}
+
+	// Check arguments
+	if volumeId == "" {
+		return nil, status.Error(codes.InvalidArgument, "Name missing in request")
+	}
+	if len(req.GetVolumeCapabilities()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume Capabilities missing in request")
+	}
+
+	params := req.GetParameters()
+	log.Trace("params:%v", params)
+
+	vcap := allowedAccessModes(cs.Driver.VolumeCaps, params)
+	if !isValidVolumeCapabilities(vcap, req.GetVolumeCapabilities()) {
+		return nil, status.Error(codes.InvalidArgument, "Requested volume capabilities are not allowed for this StorageClass")
+	}
+
+	capacity := req.GetCapacityRange().GetRequiredBytes()
+	capacityGB := capacity >> 30
+	if capacityGB == 0 {
+		return nil, status.Error(codes.InvalidArgument, "required bytes less than 1GB")
+	}
+	seaweedFsVolumeCount := capacityGB / 30
+	if seaweedFsVolumeCount == 0 {
+		seaweedFsVolumeCount = 1
+	}
+
+	if err := filer_pb.Mkdir(cs.Driver, driver.BucketsDir, volumeId, nil); err != nil {
+		return nil, fmt.Errorf("Error setting bucket metadata: %v", err)
+	}
+
+	topology := preferredTopology(req.GetAccessibilityRequirements())
+	if topology.datacenter != "" || topology.rack != "" {
+		attrs := make(map[string][]byte, 2)
+		if topology.datacenter != "" {
+			attrs[bucketDatacenterAttr] = []byte(topology.datacenter)
+		}
+		if topology.rack != "" {
+			attrs[bucketRackAttr] = []byte(topology.rack)
+		}
+		if err := setEntryExtended(cs.Driver, driver.BucketsDir, volumeId, attrs); err != nil {
+			return nil, fmt.Errorf("Error setting placement for bucket %s: %v", volumeId, err)
+		}
+	}
+
+	if snapshot := req.GetVolumeContentSource().GetSnapshot(); snapshot != nil {
+		sourceVolumeId, err := findSnapshotSourceVolume(cs.Driver, snapshot.GetSnapshotId())
+		if err != nil {
+			return nil, fmt.Errorf("Error locating snapshot %s: %v", snapshot.GetSnapshotId(), err)
+		}
+		if sourceVolumeId == "" {
+			return nil, status.Error(codes.NotFound, fmt.Sprintf("Snapshot %s does not exist", snapshot.GetSnapshotId()))
+		}
+
+		snapshotDir := fmt.Sprintf("%s/%s/%s", snapshotsDir, sourceVolumeId, snapshot.GetSnapshotId())
+		if err := cloneBucketTree(cs.Driver, snapshotDir, driver.BucketsDir+"/"+volumeId); err != nil {
+			return nil, fmt.Errorf("Error cloning snapshot %s into volume %s: %v", snapshot.GetSnapshotId(), volumeId, err)
+		}
+		if err := setEntryExtended(cs.Driver, driver.BucketsDir, volumeId, map[string][]byte{
+			snapshotSourceSnapshotAttr: []byte(snapshot.GetSnapshotId()),
+		}); err != nil {
+			return nil, fmt.Errorf("Error recording snapshot source for volume %s: %v", volumeId, err)
+		}
+	}
+
+	log.Tracef("create volume %s", volumeId)
+
+	volumeContext := make(map[string]string, len(params)+1)
+	for k, v := range params {
+		volumeContext[k] = v
+	}
+	volumeContext[driver.AccessModeContextKey] = req.GetVolumeCapabilities()[0].GetAccessMode().GetMode().String()
+
+	volume := &csi.Volume{
+		VolumeId:      volumeId,
+		CapacityBytes: capacity, // 0, // seaweedFsVolumeCount * 1024 * 1024 * 30,
+		VolumeContext: volumeContext,
+		ContentSource: req.GetVolumeContentSource(),
+	}
+	if topology.datacenter != "" || topology.rack != "" {
+		volume.AccessibleTopology = []*csi.Topology{topology.toCSITopology()}
+	}
+
+	return &csi.CreateVolumeResponse{Volume: volume}, nil
+}
+
+// bucketDatacenterAttr and bucketRackAttr record the placement hint
+// CreateVolume honored for a bucket. SeaweedFS has no filer_pb RPC for
+// per-bucket placement, so these are recorded as extended attributes on the
+// bucket directory rather than enforced collection-level placement.
+const (
+	bucketDatacenterAttr = "seaweedfs-csi/datacenter"
+	bucketRackAttr       = "seaweedfs-csi/rack"
+)
+
+// volumeTopology is the DC/rack pair CreateVolume honored for a bucket,
+// taken from the first preferred segment in AccessibilityRequirements that
+// sets them.
+type volumeTopology struct {
+	datacenter string
+	rack       string
+}
+
+func (t volumeTopology) toCSITopology() *csi.Topology {
+	segments := make(map[string]string)
+	if t.datacenter != "" {
+		segments[driver.TopologyDatacenterKey] = t.datacenter
+	}
+	if t.rack != "" {
+		segments[driver.TopologyRackKey] = t.rack
+	}
+	return &csi.Topology{Segments: segments}
+}
+
+func preferredTopology(req *csi.TopologyRequirement) volumeTopology {
+	var t volumeTopology
+	for _, preferred := range req.GetPreferred() {
+		if dc, ok := preferred.GetSegments()[driver.TopologyDatacenterKey]; ok && t.datacenter == "" {
+			t.datacenter = dc
+		}
+		if rack, ok := preferred.GetSegments()[driver.TopologyRackKey]; ok && t.rack == "" {
+			t.rack = rack
+		}
+	}
+	return t
+}
+
+func (cs *ControllerServer) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequest) (*csi.DeleteVolumeResponse, error) {
+
+	volumeId := req.VolumeId
+
+	// Check arguments
+	if volumeId == "" {
+		return nil, status.Error(codes.InvalidArgument, "Volume ID missing in request")
+	}
+
+	if err := cs.Driver.ValidateControllerServiceRequest(csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME); err != nil {
+		log.Tracef("Invalid delete volume req: %v", req)
+		return nil, err
+	}
+	log.Tracef("Deleting volume %s", volumeId)
+
+	hasSnapshots, err := volumeHasSnapshots(cs.Driver, volumeId)
+	if err != nil {
+		return nil, fmt.Errorf("Error checking snapshots of volume %s: %v", volumeId, err)
+	}
+	if hasSnapshots {
+		return nil, status.Error(codes.FailedPrecondition, fmt.Sprintf("Volume %s has snapshots; delete them first", volumeId))
+	}
+
+	if err := filer_pb.Remove(cs.Driver, driver.BucketsDir, volumeId, true, true, true, false, nil); err != nil {
+		return nil, fmt.Errorf("Error setting bucket metadata: %v", err)
+	}
+
+	return &csi.DeleteVolumeResponse{}, nil
+}
+
+func (cs *ControllerServer) ControllerPublishVolume(ctx context.Context, req *csi.ControllerPublishVolumeRequest) (*csi.ControllerPublishVolumeResponse, error) {
+	return &csi.ControllerPublishVolumeResponse{}, nil
+}
+
+func (cs *ControllerServer) ControllerUnpublishVolume(ctx context.Context, req *csi.ControllerUnpublishVolumeRequest) (*csi.ControllerUnpublishVolumeResponse, error) {
+	return &csi.ControllerUnpublishVolumeResponse{}, nil
+}
+
+func (cs *ControllerServer) ValidateVolumeCapabilities(ctx context.Context, req *csi.ValidateVolumeCapabilitiesRequest) (*csi.ValidateVolumeCapabilitiesResponse, error) {
+
+	// Check arguments
+	if req.GetVolumeId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "Volume ID missing in request")
+	}
+	if req.GetVolumeCapabilities() == nil {
+		return nil, status.Error(codes.InvalidArgument, "Volume capabilities missing in request")
+	}
+
+	exists, err := filer_pb.Exists(cs.Driver, driver.BucketsDir, req.GetVolumeId(), true)
+	if err != nil {
+		return nil, fmt.Errorf("Error checking bucket %s exists: %v", req.GetVolumeId(), err)
+	}
+	if !exists {
+		// return an error if the volume requested does not exist
+		return nil, status.Error(codes.NotFound, fmt.Sprintf("Volume with id %s does not exist", req.GetVolumeId()))
+	}
+
+	volCaps := req.GetVolumeCapabilities()
+	if len(volCaps) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Volume capabilities not provided")
+	}
+	vcap := allowedAccessModes(cs.Driver.VolumeCaps, req.GetParameters())
+	var confirmed *csi.ValidateVolumeCapabilitiesResponse_Confirmed
+	if isValidVolumeCapabilities(vcap, volCaps) {
+		confirmed = &csi.ValidateVolumeCapabilitiesResponse_Confirmed{VolumeCapabilities: volCaps}
+	}
+
+	return &csi.ValidateVolumeCapabilitiesResponse{
+		Confirmed: confirmed,
+	}, nil
+
+}
+
+func (cs *ControllerServer) ListVolumes(ctx context.Context, req *csi.ListVolumesRequest) (*csi.ListVolumesResponse, error) {
+
+	if err := cs.Driver.ValidateControllerServiceRequest(csi.ControllerServiceCapability_RPC_LIST_VOLUMES); err != nil {
+		log.Tracef("invalid list volumes req: %v", req)
+		return nil, err
+	}
+
+	var bucketEntries []*filer_pb.Entry
+	var nextToken string
+
+	err := cs.Driver.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+		stream, err := client.ListEntries(context.Background(), &filer_pb.ListEntriesRequest{
+			Directory:         driver.BucketsDir,
+			StartFromFileName: req.GetStartingToken(),
+			Limit:             uint32(req.GetMaxEntries()),
+		})
+		if err != nil {
+			return err
+		}
+
+		for {
+			resp, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if !resp.Entry.IsDirectory {
+				continue
+			}
+			bucketEntries = append(bucketEntries, resp.Entry)
+			if req.GetMaxEntries() > 0 && int32(len(bucketEntries)) == req.GetMaxEntries() {
+				nextToken = resp.Entry.Name
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error listing volumes: %v", err)
+	}
+
+	var volumeEntries []*csi.ListVolumesResponse_Entry
+	for _, bucket := range bucketEntries {
+		capacityBytes, err := bucketSizeBytes(cs.Driver, driver.BucketsDir+"/"+bucket.Name)
+		if err != nil {
+			return nil, fmt.Errorf("Error measuring bucket %s: %v", bucket.Name, err)
+		}
+		volumeEntries = append(volumeEntries, &csi.ListVolumesResponse_Entry{
+			Volume: &csi.Volume{
+				VolumeId:      bucket.Name,
+				CapacityBytes: capacityBytes,
+			},
+		})
+	}
+
+	return &csi.ListVolumesResponse{
+		Entries:   volumeEntries,
+		NextToken: nextToken,
+	}, nil
+}
+
+func (cs *ControllerServer) GetCapacity(ctx context.Context, req *csi.GetCapacityRequest) (*csi.GetCapacityResponse, error) {
+
+	if err := cs.Driver.ValidateControllerServiceRequest(csi.ControllerServiceCapability_RPC_GET_CAPACITY); err != nil {
+		log.Tracef("invalid get capacity req: %v", req)
+		return nil, err
+	}
+
+	params := req.GetParameters()
+	statsReq := &filer_pb.StatisticsRequest{
+		Collection:  params["collection"],
+		Replication: params["replication"],
+	}
+
+	var availableBytes int64
+	err := cs.Driver.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+		resp, err := client.Statistics(context.Background(), statsReq)
+		if err != nil {
+			return err
+		}
+		availableBytes = int64(resp.TotalSize) - int64(resp.UsedSize)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error fetching filer statistics: %v", err)
+	}
+
+	return &csi.GetCapacityResponse{
+		AvailableCapacity: availableBytes,
+	}, nil
+}
+
+// ControllerGetCapabilities implements the default GRPC callout.
+// Default supports all capabilities
+func (cs *ControllerServer) ControllerGetCapabilities(ctx context.Context, req *csi.ControllerGetCapabilitiesRequest) (*csi.ControllerGetCapabilitiesResponse, error) {
+	log.Tracef("Using default ControllerGetCapabilities")
+
+	return &csi.ControllerGetCapabilitiesResponse{
+		Capabilities: cs.Driver.ControllerCaps,
+	}, nil
+}
+
+func (cs *ControllerServer) CreateSnapshot(ctx context.Context, req *csi.CreateSnapshotRequest) (*csi.CreateSnapshotResponse, error) {
+
+	if err := cs.Driver.ValidateControllerServiceRequest(csi.ControllerServiceCapability_RPC_CREATE_DELETE_SNAPSHOT); err != nil {
+		log.Tracef("invalid create snapshot req: %v", req)
+		return nil, err
+	}
+
+	sourceVolumeId := req.GetSourceVolumeId()
+	if sourceVolumeId == "" {
+		return nil, status.Error(codes.InvalidArgument, "Source volume ID missing in request")
+	}
+	if req.GetName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "Name missing in request")
+	}
+
+	exists, err := filer_pb.Exists(cs.Driver, driver.BucketsDir, sourceVolumeId, true)
+	if err != nil {
+		return nil, fmt.Errorf("Error checking bucket %s exists: %v", sourceVolumeId, err)
+	}
+	if !exists {
+		return nil, status.Error(codes.NotFound, fmt.Sprintf("Source volume %s does not exist", sourceVolumeId))
+	}
+
+	snapshotId := sanitizeVolumeId(req.GetName())
+	creationTime := time.Now()
+
+	if err := ensureDir(cs.Driver, "/", "snapshots"); err != nil {
+		return nil, fmt.Errorf("Error creating snapshots directory: %v", err)
+	}
+	if err := ensureDir(cs.Driver, snapshotsDir, sourceVolumeId); err != nil {
+		return nil, fmt.Errorf("Error creating snapshot parent directory: %v", err)
+	}
+
+	snapshotParentDir := snapshotsDir + "/" + sourceVolumeId
+	if err := filer_pb.Mkdir(cs.Driver, snapshotParentDir, snapshotId, func(entry *filer_pb.Entry) {
+		entry.Extended = map[string][]byte{
+			snapshotSourceVolumeAttr: []byte(sourceVolumeId),
+			snapshotCreatedAtAttr:    []byte(strconv.FormatInt(creationTime.Unix(), 10)),
+		}
+	}); err != nil {
+		return nil, fmt.Errorf("Error creating snapshot directory: %v", err)
+	}
+
+	if err := cloneBucketTree(cs.Driver, driver.BucketsDir+"/"+sourceVolumeId, snapshotParentDir+"/"+snapshotId); err != nil {
+		return nil, fmt.Errorf("Error cloning volume %s into snapshot %s: %v", sourceVolumeId, snapshotId, err)
+	}
+
+	creationTimestamp, err := ptypes.TimestampProto(creationTime)
+	if err != nil {
+		return nil, fmt.Errorf("Error encoding snapshot creation time: %v", err)
+	}
+
+	log.Tracef("created snapshot %s from volume %s", snapshotId, sourceVolumeId)
+
+	return &csi.CreateSnapshotResponse{
+		Snapshot: &csi.Snapshot{
+			SnapshotId:     snapshotId,
+			SourceVolumeId: sourceVolumeId,
+			CreationTime:   creationTimestamp,
+			ReadyToUse:     true,
+		},
+	}, nil
+}
+
+func (cs *ControllerServer) DeleteSnapshot(ctx context.Context, req *csi.DeleteSnapshotRequest) (*csi.DeleteSnapshotResponse, error) {
+
+	snapshotId := req.GetSnapshotId()
+	if snapshotId == "" {
+		return nil, status.Error(codes.InvalidArgument, "Snapshot ID missing in request")
+	}
+
+	if err := cs.Driver.ValidateControllerServiceRequest(csi.ControllerServiceCapability_RPC_CREATE_DELETE_SNAPSHOT); err != nil {
+		log.Tracef("invalid delete snapshot req: %v", req)
+		return nil, err
+	}
+
+	sourceVolumeId, err := findSnapshotSourceVolume(cs.Driver, snapshotId)
+	if err != nil {
+		return nil, fmt.Errorf("Error locating snapshot %s: %v", snapshotId, err)
+	}
+	if sourceVolumeId == "" {
+		// DeleteSnapshot must be idempotent; nothing to do if it's already gone.
+		return &csi.DeleteSnapshotResponse{}, nil
+	}
+
+	clonedVolumeId, err := findVolumeClonedFromSnapshot(cs.Driver, snapshotId)
+	if err != nil {
+		return nil, fmt.Errorf("Error checking clones of snapshot %s: %v", snapshotId, err)
+	}
+	if clonedVolumeId != "" {
+		return nil, status.Error(codes.FailedPrecondition, fmt.Sprintf("Snapshot %s was used to create volume %s; delete it first", snapshotId, clonedVolumeId))
+	}
+
+	if err := filer_pb.Remove(cs.Driver, snapshotsDir+"/"+sourceVolumeId, snapshotId, true, true, true, false, nil); err != nil {
+		return nil, fmt.Errorf("Error removing snapshot %s: %v", snapshotId, err)
+	}
+
+	return &csi.DeleteSnapshotResponse{}, nil
+}
+
+func (cs *ControllerServer) ListSnapshots(ctx context.Context, req *csi.ListSnapshotsRequest) (*csi.ListSnapshotsResponse, error) {
+
+	if err := cs.Driver.ValidateControllerServiceRequest(csi.ControllerServiceCapability_RPC_LIST_SNAPSHOTS); err != nil {
+		log.Tracef("invalid list snapshots req: %v", req)
+		return nil, err
+	}
+
+	resumeVolumeId, resumeSnapshotId := splitSnapshotToken(req.GetStartingToken())
+
+	var snapshotEntries []*csi.ListSnapshotsResponse_Entry
+	var nextToken string
+
+	err := cs.Driver.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+		volumeDirs, err := client.ListEntries(context.Background(), &filer_pb.ListEntriesRequest{
+			Directory: snapshotsDir,
+		})
+		if err != nil {
+			if strings.Contains(err.Error(), "not found") {
+				return nil
+			}
+			return err
+		}
+
+		for {
+			volumeDir, err := volumeDirs.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if !volumeDir.Entry.IsDirectory {
+				continue
+			}
+			if req.GetSourceVolumeId() != "" && req.GetSourceVolumeId() != volumeDir.Entry.Name {
+				continue
+			}
+
+			startFromSnapshotName := ""
+			if resumeVolumeId != "" {
+				if volumeDir.Entry.Name != resumeVolumeId {
+					continue
+				}
+				startFromSnapshotName = resumeSnapshotId
+				resumeVolumeId = "" // only the volume dir we paused in resumes mid-way
+			}
+
+			// MaxEntries only bounds the filer listing when every entry we see
+			// is one we'll keep; a SnapshotId filter means we're scanning for
+			// one specific entry, and capping the listing could skip past it.
+			var limit uint32
+			if req.GetMaxEntries() > 0 && req.GetSnapshotId() == "" {
+				limit = uint32(req.GetMaxEntries() - int32(len(snapshotEntries)))
+			}
+
+			snapshots, err := client.ListEntries(context.Background(), &filer_pb.ListEntriesRequest{
+				Directory:         snapshotsDir + "/" + volumeDir.Entry.Name,
+				StartFromFileName: startFromSnapshotName,
+				Limit:             limit,
+			})
+			if err != nil {
+				return err
+			}
+			for {
+				snapshot, err := snapshots.Recv()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					return err
+				}
+				if req.GetSnapshotId() != "" && req.GetSnapshotId() != snapshot.Entry.Name {
+					continue
+				}
+				snapshotEntries = append(snapshotEntries, &csi.ListSnapshotsResponse_Entry{
+					Snapshot: snapshotFromEntry(volumeDir.Entry.Name, snapshot.Entry),
+				})
+				if req.GetMaxEntries() > 0 && int32(len(snapshotEntries)) == req.GetMaxEntries() {
+					nextToken = snapshotToken(volumeDir.Entry.Name, snapshot.Entry.Name)
+					return nil
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error listing snapshots: %v", err)
+	}
+
+	return &csi.ListSnapshotsResponse{Entries: snapshotEntries, NextToken: nextToken}, nil
+}
+
+func (cs *ControllerServer) ControllerExpandVolume(ctx context.Context, req *csi.ControllerExpandVolumeRequest) (*csi.ControllerExpandVolumeResponse, error) {
+
+	volumeId := req.GetVolumeId()
+
+	if err := cs.Driver.ValidateControllerServiceRequest(csi.ControllerServiceCapability_RPC_EXPAND_VOLUME); err != nil {
+		log.Tracef("invalid expand volume req: %v", req)
+		return nil, err
+	}
+
+	// Check arguments
+	if volumeId == "" {
+		return nil, status.Error(codes.InvalidArgument, "Volume ID missing in request")
+	}
+	capacity := req.GetCapacityRange().GetRequiredBytes()
+	if capacity <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "required bytes must be greater than 0")
+	}
+
+	exists, err := filer_pb.Exists(cs.Driver, driver.BucketsDir, volumeId, true)
+	if err != nil {
+		return nil, fmt.Errorf("Error checking bucket %s exists: %v", volumeId, err)
+	}
+	if !exists {
+		return nil, status.Error(codes.NotFound, fmt.Sprintf("Volume with id %s does not exist", volumeId))
+	}
+
+	if err := setEntryExtended(cs.Driver, driver.BucketsDir, volumeId, map[string][]byte{
+		bucketQuotaAttr: []byte(strconv.FormatInt(capacity, 10)),
+	}); err != nil {
+		return nil, fmt.Errorf("Error setting quota for bucket %s: %v", volumeId, err)
+	}
+
+	log.Tracef("expanded volume %s to %d bytes", volumeId, capacity)
+
+	return &csi.ControllerExpandVolumeResponse{
+		CapacityBytes:         capacity,
+		NodeExpansionRequired: true,
+	}, nil
+}
+
+func sanitizeVolumeId(volumeId string) string {
+	volumeId = strings.ToLower(volumeId)
+	if len(volumeId) > 63 {
+		h := sha1.New()
+		io.WriteString(h, volumeId)
+		volumeId = hex.EncodeToString(h.Sum(nil))
+	}
+	return volumeId
+}
+
+func isValidVolumeCapabilities(driverVolumeCaps []*csi.VolumeCapability_AccessMode, volCaps []*csi.VolumeCapability) bool {
+	hasSupport := func(cap *csi.VolumeCapability) bool {
+		for _, c := range driverVolumeCaps {
+			if c.GetMode() == cap.AccessMode.GetMode() {
+				return true
+			}
+		}
+		return false
+	}
+
+	foundAll := true
+	for _, c := range volCaps {
+		if !hasSupport(c) {
+			foundAll = false
+		}
+	}
+	return foundAll
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/Ruakij/seaweedfs-csi-driver/pkg/driver"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+)
+
+func accessMode(mode csi.VolumeCapability_AccessMode_Mode) *csi.VolumeCapability_AccessMode {
+	return &csi.VolumeCapability_AccessMode{Mode: mode}
+}
+
+func TestAllowedAccessModes(t *testing.T) {
+	driverVcap := []*csi.VolumeCapability_AccessMode{
+		accessMode(csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+		accessMode(csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY),
+		accessMode(csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER),
+	}
+
+	tests := []struct {
+		name   string
+		params map[string]string
+		want   int
+	}{
+		{"no restriction returns driver modes", nil, len(driverVcap)},
+		{"empty restriction returns driver modes", map[string]string{allowedAccessModesParam: ""}, len(driverVcap)},
+		{"restricts to the listed modes", map[string]string{allowedAccessModesParam: "SINGLE_NODE_WRITER, MULTI_NODE_READER_ONLY"}, 2},
+		{"mode the driver does not advertise matches nothing", map[string]string{allowedAccessModesParam: "MULTI_NODE_SINGLE_WRITER"}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := allowedAccessModes(driverVcap, tt.params)
+			if len(got) != tt.want {
+				t.Errorf("allowedAccessModes() = %d modes, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestIsValidVolumeCapabilities(t *testing.T) {
+	driverVcap := []*csi.VolumeCapability_AccessMode{
+		accessMode(csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+	}
+
+	writer := &csi.VolumeCapability{AccessMode: accessMode(csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER)}
+	reader := &csi.VolumeCapability{AccessMode: accessMode(csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY)}
+
+	if !isValidVolumeCapabilities(driverVcap, []*csi.VolumeCapability{writer}) {
+		t.Error("expected a capability present in driverVcap to be valid")
+	}
+	if isValidVolumeCapabilities(driverVcap, []*csi.VolumeCapability{reader}) {
+		t.Error("expected a capability absent from driverVcap to be invalid")
+	}
+	if isValidVolumeCapabilities(driverVcap, []*csi.VolumeCapability{writer, reader}) {
+		t.Error("expected a mixed request with one disallowed mode to be invalid")
+	}
+}
+
+func topologySegment(segments map[string]string) *csi.Topology {
+	return &csi.Topology{Segments: segments}
+}
+
+func TestPreferredTopology(t *testing.T) {
+	t.Run("no requirement yields an empty topology", func(t *testing.T) {
+		got := preferredTopology(nil)
+		if got.datacenter != "" || got.rack != "" {
+			t.Errorf("preferredTopology(nil) = %+v, want zero value", got)
+		}
+	})
+
+	t.Run("takes the first preferred segment that sets each key", func(t *testing.T) {
+		req := &csi.TopologyRequirement{
+			Preferred: []*csi.Topology{
+				topologySegment(map[string]string{driver.TopologyDatacenterKey: "dc1"}),
+				topologySegment(map[string]string{driver.TopologyDatacenterKey: "dc2", driver.TopologyRackKey: "rack2"}),
+			},
+		}
+		got := preferredTopology(req)
+		if got.datacenter != "dc1" {
+			t.Errorf("datacenter = %q, want dc1 (first preferred entry wins)", got.datacenter)
+		}
+		if got.rack != "rack2" {
+			t.Errorf("rack = %q, want rack2 (only entry that sets it)", got.rack)
+		}
+	})
+}
@@ -0,0 +1,226 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Ruakij/seaweedfs-csi-driver/pkg/driver"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/golang/protobuf/ptypes/timestamp"
+)
+
+const (
+	snapshotsDir = "/snapshots"
+
+	// Extended attributes persisted on the snapshot directory entry so
+	// ListSnapshots can reconstruct a csi.Snapshot without extra bookkeeping.
+	snapshotSourceVolumeAttr = "seaweedfs-csi/source-volume-id"
+	snapshotCreatedAtAttr    = "seaweedfs-csi/created-at"
+
+	// snapshotSourceSnapshotAttr is stamped on a bucket directory created via
+	// VolumeContentSource_Snapshot, recording which snapshot it was cloned
+	// from. cloneBucketTree copies chunks by reference, so the clone and its
+	// source snapshot share needles until one side is deleted; DeleteSnapshot
+	// uses this attribute to refuse deleting a snapshot that still has clones.
+	snapshotSourceSnapshotAttr = "seaweedfs-csi/source-snapshot-id"
+)
+
+// cloneBucketTree recursively copies the entries under sourceDir into
+// targetDir. Chunks are copied by reference rather than re-uploaded, so the
+// clone shares needles with its source until one side is garbage collected.
+func cloneBucketTree(csiDriver *driver.CSIDriver, sourceDir, targetDir string) error {
+	return csiDriver.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+		stream, err := client.ListEntries(context.Background(), &filer_pb.ListEntriesRequest{
+			Directory: sourceDir,
+		})
+		if err != nil {
+			return err
+		}
+
+		for {
+			resp, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+
+			entry := resp.Entry
+			if _, err := client.CreateEntry(context.Background(), &filer_pb.CreateEntryRequest{
+				Directory: targetDir,
+				Entry: &filer_pb.Entry{
+					Name:        entry.Name,
+					IsDirectory: entry.IsDirectory,
+					Attributes:  entry.Attributes,
+					Chunks:      entry.Chunks,
+				},
+			}); err != nil {
+				return fmt.Errorf("cloning %s/%s into %s: %v", sourceDir, entry.Name, targetDir, err)
+			}
+
+			if entry.IsDirectory {
+				if err := cloneBucketTree(csiDriver, sourceDir+"/"+entry.Name, targetDir+"/"+entry.Name); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// findSnapshotSourceVolume scans /snapshots/<volumeId>/ directories for one
+// containing snapshotId, since CSI only addresses snapshots by their own ID.
+func findSnapshotSourceVolume(csiDriver *driver.CSIDriver, snapshotId string) (string, error) {
+	var sourceVolumeId string
+
+	err := csiDriver.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+		stream, err := client.ListEntries(context.Background(), &filer_pb.ListEntriesRequest{
+			Directory: snapshotsDir,
+		})
+		if err != nil {
+			if strings.Contains(err.Error(), "not found") {
+				return nil
+			}
+			return err
+		}
+
+		for {
+			resp, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if !resp.Entry.IsDirectory {
+				continue
+			}
+
+			exists, err := filer_pb.Exists(csiDriver, snapshotsDir+"/"+resp.Entry.Name, snapshotId, true)
+			if err != nil {
+				return err
+			}
+			if exists {
+				sourceVolumeId = resp.Entry.Name
+				return nil
+			}
+		}
+		return nil
+	})
+
+	return sourceVolumeId, err
+}
+
+// volumeHasSnapshots reports whether /snapshots/<volumeId> holds any
+// snapshot, used to refuse DeleteVolume while one of its snapshots is still
+// sharing chunks with it.
+func volumeHasSnapshots(csiDriver *driver.CSIDriver, volumeId string) (bool, error) {
+	hasAny := false
+
+	err := csiDriver.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+		stream, err := client.ListEntries(context.Background(), &filer_pb.ListEntriesRequest{
+			Directory: snapshotsDir + "/" + volumeId,
+			Limit:     1,
+		})
+		if err != nil {
+			if strings.Contains(err.Error(), "not found") {
+				return nil
+			}
+			return err
+		}
+
+		if _, err := stream.Recv(); err == nil {
+			hasAny = true
+		} else if err != io.EOF {
+			return err
+		}
+		return nil
+	})
+
+	return hasAny, err
+}
+
+// findVolumeClonedFromSnapshot scans /buckets/ for a bucket stamped with
+// snapshotSourceSnapshotAttr pointing at snapshotId, used to refuse
+// DeleteSnapshot while a clone is still sharing chunks with it.
+func findVolumeClonedFromSnapshot(csiDriver *driver.CSIDriver, snapshotId string) (string, error) {
+	var clonedVolumeId string
+
+	err := csiDriver.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+		stream, err := client.ListEntries(context.Background(), &filer_pb.ListEntriesRequest{
+			Directory: driver.BucketsDir,
+		})
+		if err != nil {
+			return err
+		}
+
+		for {
+			resp, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if !resp.Entry.IsDirectory {
+				continue
+			}
+			if string(resp.Entry.Extended[snapshotSourceSnapshotAttr]) == snapshotId {
+				clonedVolumeId = resp.Entry.Name
+				return nil
+			}
+		}
+		return nil
+	})
+
+	return clonedVolumeId, err
+}
+
+// snapshotPageTokenSep joins the volume/snapshot pair ListSnapshots pages
+// over, since a snapshot's position isn't unique by name alone (the same
+// snapshot name could in principle exist under two different volumes).
+const snapshotPageTokenSep = "/"
+
+// snapshotToken builds a ListSnapshots NextToken identifying the last
+// snapshot returned, so the following call can resume from it.
+func snapshotToken(volumeId, snapshotId string) string {
+	return volumeId + snapshotPageTokenSep + snapshotId
+}
+
+// splitSnapshotToken parses a ListSnapshots StartingToken back into the
+// volume/snapshot pair snapshotToken encoded. An empty or malformed token
+// yields two empty strings, meaning "start from the beginning".
+func splitSnapshotToken(token string) (volumeId, snapshotId string) {
+	idx := strings.Index(token, snapshotPageTokenSep)
+	if idx < 0 {
+		return "", ""
+	}
+	return token[:idx], token[idx+1:]
+}
+
+func snapshotFromEntry(sourceVolumeId string, entry *filer_pb.Entry) *csi.Snapshot {
+	var sizeBytes int64
+	for _, chunk := range entry.Chunks {
+		sizeBytes += int64(chunk.Size)
+	}
+
+	creationTime := &timestamp.Timestamp{}
+	if raw, ok := entry.Extended[snapshotCreatedAtAttr]; ok {
+		if unixSeconds, err := strconv.ParseInt(string(raw), 10, 64); err == nil {
+			creationTime.Seconds = unixSeconds
+		}
+	}
+
+	return &csi.Snapshot{
+		SnapshotId:     entry.Name,
+		SourceVolumeId: sourceVolumeId,
+		SizeBytes:      sizeBytes,
+		CreationTime:   creationTime,
+		ReadyToUse:     true,
+	}
+}
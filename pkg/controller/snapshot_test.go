@@ -0,0 +1,33 @@
+package controller
+
+import "testing"
+
+func TestSnapshotTokenRoundTrip(t *testing.T) {
+	tests := []struct {
+		name       string
+		volumeId   string
+		snapshotId string
+	}{
+		{"simple names", "vol-a", "snap-1"},
+		{"snapshotId containing slashes", "vol-a", "2026/07/27"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := snapshotToken(tt.volumeId, tt.snapshotId)
+			gotVolumeId, gotSnapshotId := splitSnapshotToken(token)
+			if gotVolumeId != tt.volumeId || gotSnapshotId != tt.snapshotId {
+				t.Errorf("splitSnapshotToken(%q) = (%q, %q), want (%q, %q)", token, gotVolumeId, gotSnapshotId, tt.volumeId, tt.snapshotId)
+			}
+		})
+	}
+}
+
+func TestSplitSnapshotTokenEmptyOrMalformed(t *testing.T) {
+	for _, token := range []string{"", "no-separator"} {
+		volumeId, snapshotId := splitSnapshotToken(token)
+		if volumeId != "" || snapshotId != "" {
+			t.Errorf("splitSnapshotToken(%q) = (%q, %q), want (\"\", \"\")", token, volumeId, snapshotId)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"context"
+	"io"
+
+	"github.com/Ruakij/seaweedfs-csi-driver/pkg/driver"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+)
+
+// bucketSizeBytes recursively sums the chunk sizes under a bucket directory,
+// the same `du`-style aggregation `weed` itself uses for folder sizes.
+func bucketSizeBytes(csiDriver *driver.CSIDriver, directory string) (int64, error) {
+	var size int64
+
+	err := csiDriver.WithFilerClient(func(client filer_pb.SeaweedFilerClient) error {
+		stream, err := client.ListEntries(context.Background(), &filer_pb.ListEntriesRequest{
+			Directory: directory,
+		})
+		if err != nil {
+			return err
+		}
+
+		for {
+			resp, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+
+			if resp.Entry.IsDirectory {
+				childSize, err := bucketSizeBytes(csiDriver, directory+"/"+resp.Entry.Name)
+				if err != nil {
+					return err
+				}
+				size += childSize
+				continue
+			}
+
+			for _, chunk := range resp.Entry.Chunks {
+				size += int64(chunk.Size)
+			}
+		}
+		return nil
+	})
+
+	return size, err
+}
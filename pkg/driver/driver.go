@@ -0,0 +1,136 @@
+// Package driver holds the CSI scaffolding shared by the controller and
+// node binaries: driver identity, capability registration and the filer_pb
+// client used to talk to the SeaweedFS filer.
+package driver
+
+import (
+	"fmt"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util/log"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	DriverName = "seaweedfs-csi-driver"
+
+	// BucketsDir is the filer directory under which the controller creates
+	// one subdirectory per CSI volume.
+	BucketsDir = "/buckets"
+
+	// Topology keys advertised by the node server and consumed by
+	// CreateVolume's AccessibilityRequirements handling.
+	TopologyDatacenterKey = "topology.seaweedfs.csi/datacenter"
+	TopologyRackKey       = "topology.seaweedfs.csi/rack"
+
+	// AccessModeContextKey is the VolumeContext key CreateVolume stamps with
+	// the negotiated access mode, so NodePublishVolume can recover it on the
+	// rare CO that republishes a volume without a VolumeCapability.
+	AccessModeContextKey = "accessMode"
+)
+
+// CSIDriver is the shared state behind the identity, controller and node
+// gRPC servers. It also implements filer_pb.FilerClient so controller and
+// node code can call filer_pb helpers (Mkdir, Remove, Exists, ...) directly
+// with the driver as the client.
+type CSIDriver struct {
+	Name    string
+	NodeID  string
+	Version string
+
+	Endpoint     string
+	FilerAddress string
+
+	GrpcDialOption grpc.DialOption
+
+	ControllerCaps []*csi.ControllerServiceCapability
+	NodeCaps       []*csi.NodeServiceCapability
+	VolumeCaps     []*csi.VolumeCapability_AccessMode
+
+	// Datacenter and Rack are this node's own placement, published via
+	// NodeGetInfo.AccessibleTopology. Unused by the controller binary.
+	Datacenter string
+	Rack       string
+}
+
+// NewCSIDriver creates the shared driver state. endpoint is the CSI gRPC
+// socket for this binary (controller or node); filerAddress is the filer the
+// controller dials over gRPC and the node passes to `weed mount -filer`.
+func NewCSIDriver(nodeID, endpoint, filerAddress, version string) *CSIDriver {
+	return &CSIDriver{
+		Name:           DriverName,
+		NodeID:         nodeID,
+		Version:        version,
+		Endpoint:       endpoint,
+		FilerAddress:   filerAddress,
+		GrpcDialOption: grpc.WithInsecure(),
+	}
+}
+
+func (d *CSIDriver) AddControllerServiceCapabilities(cl []csi.ControllerServiceCapability_RPC_Type) {
+	var csc []*csi.ControllerServiceCapability
+	for _, c := range cl {
+		log.Tracef("Enabling controller service capability: %v", c.String())
+		csc = append(csc, &csi.ControllerServiceCapability{
+			Type: &csi.ControllerServiceCapability_Rpc{
+				Rpc: &csi.ControllerServiceCapability_RPC{Type: c},
+			},
+		})
+	}
+	d.ControllerCaps = csc
+}
+
+func (d *CSIDriver) AddNodeServiceCapabilities(nl []csi.NodeServiceCapability_RPC_Type) {
+	var nsc []*csi.NodeServiceCapability
+	for _, n := range nl {
+		log.Tracef("Enabling node service capability: %v", n.String())
+		nsc = append(nsc, &csi.NodeServiceCapability{
+			Type: &csi.NodeServiceCapability_Rpc{
+				Rpc: &csi.NodeServiceCapability_RPC{Type: n},
+			},
+		})
+	}
+	d.NodeCaps = nsc
+}
+
+func (d *CSIDriver) AddVolumeCapabilityAccessModes(vc []csi.VolumeCapability_AccessMode_Mode) []*csi.VolumeCapability_AccessMode {
+	var vca []*csi.VolumeCapability_AccessMode
+	for _, c := range vc {
+		log.Tracef("Enabling volume access mode: %v", c.String())
+		vca = append(vca, &csi.VolumeCapability_AccessMode{Mode: c})
+	}
+	d.VolumeCaps = vca
+	return vca
+}
+
+// ValidateControllerServiceRequest checks that the requested RPC is one of
+// the capabilities advertised by ControllerGetCapabilities.
+func (d *CSIDriver) ValidateControllerServiceRequest(c csi.ControllerServiceCapability_RPC_Type) error {
+	if c == csi.ControllerServiceCapability_RPC_UNKNOWN {
+		return nil
+	}
+
+	for _, cap := range d.ControllerCaps {
+		if rpc := cap.GetRpc(); rpc != nil && rpc.GetType() == c {
+			return nil
+		}
+	}
+
+	return status.Error(codes.InvalidArgument, fmt.Sprintf("unsupported controller service capability: %s", c.String()))
+}
+
+// WithFilerClient implements filer_pb.FilerClient so the driver can be
+// passed directly to filer_pb helper functions.
+func (d *CSIDriver) WithFilerClient(fn func(filer_pb.SeaweedFilerClient) error) error {
+	conn, err := grpc.Dial(d.FilerAddress, d.GrpcDialOption)
+	if err != nil {
+		return fmt.Errorf("fail to dial %s: %v", d.FilerAddress, err)
+	}
+	defer conn.Close()
+
+	client := filer_pb.NewSeaweedFilerClient(conn)
+	return fn(client)
+}
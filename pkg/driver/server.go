@@ -0,0 +1,77 @@
+package driver
+
+import (
+	"net"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/chrislusf/seaweedfs/weed/util/log"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc"
+)
+
+// NonBlockingGRPCServer runs the CSI gRPC endpoint in the background so
+// main() can wait on it without blocking other startup work.
+type NonBlockingGRPCServer struct {
+	wg     sync.WaitGroup
+	server *grpc.Server
+}
+
+// Start registers whichever of ids/cs/ns are non-nil. The controller binary
+// passes nil for ns, the node binary passes nil for cs.
+func (s *NonBlockingGRPCServer) Start(endpoint string, ids csi.IdentityServer, cs csi.ControllerServer, ns csi.NodeServer) {
+	s.wg.Add(1)
+	go s.serve(endpoint, ids, cs, ns)
+}
+
+func (s *NonBlockingGRPCServer) Wait() {
+	s.wg.Wait()
+}
+
+func (s *NonBlockingGRPCServer) Stop() {
+	s.server.GracefulStop()
+}
+
+func (s *NonBlockingGRPCServer) serve(endpoint string, ids csi.IdentityServer, cs csi.ControllerServer, ns csi.NodeServer) {
+	defer s.wg.Done()
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		log.Fatalf("Failed to parse endpoint %s: %v", endpoint, err)
+	}
+
+	var addr string
+	if u.Scheme == "unix" {
+		addr = u.Path
+		if err := os.Remove(addr); err != nil && !os.IsNotExist(err) {
+			log.Fatalf("Failed to remove existing socket %s: %v", addr, err)
+		}
+	} else {
+		addr = u.Host
+	}
+
+	listener, err := net.Listen(u.Scheme, addr)
+	if err != nil {
+		log.Fatalf("Failed to listen on %s: %v", endpoint, err)
+	}
+
+	server := grpc.NewServer()
+	s.server = server
+
+	if ids != nil {
+		csi.RegisterIdentityServer(server, ids)
+	}
+	if cs != nil {
+		csi.RegisterControllerServer(server, cs)
+	}
+	if ns != nil {
+		csi.RegisterNodeServer(server, ns)
+	}
+
+	log.Tracef("Listening for connections on %#v", listener.Addr())
+
+	if err := server.Serve(listener); err != nil {
+		log.Fatalf("gRPC server failed: %v", err)
+	}
+}
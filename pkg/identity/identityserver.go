@@ -0,0 +1,63 @@
+package identity
+
+import (
+	"context"
+
+	"github.com/Ruakij/seaweedfs-csi-driver/pkg/driver"
+	"github.com/chrislusf/seaweedfs/weed/util/log"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type IdentityServer struct {
+	Driver *driver.CSIDriver
+}
+
+var _ = csi.IdentityServer(&IdentityServer{})
+
+func (ids *IdentityServer) GetPluginInfo(ctx context.Context, req *csi.GetPluginInfoRequest) (*csi.GetPluginInfoResponse, error) {
+	if ids.Driver.Name == "" {
+		return nil, status.Error(codes.Unavailable, "Driver name not configured")
+	}
+
+	return &csi.GetPluginInfoResponse{
+		Name:          ids.Driver.Name,
+		VendorVersion: ids.Driver.Version,
+	}, nil
+}
+
+func (ids *IdentityServer) Probe(ctx context.Context, req *csi.ProbeRequest) (*csi.ProbeResponse, error) {
+	return &csi.ProbeResponse{}, nil
+}
+
+func (ids *IdentityServer) GetPluginCapabilities(ctx context.Context, req *csi.GetPluginCapabilitiesRequest) (*csi.GetPluginCapabilitiesResponse, error) {
+	log.Tracef("Using default GetPluginCapabilities")
+
+	var capabilities []*csi.PluginCapability
+
+	// Only the controller binary registers controller service capabilities,
+	// so this naturally reports nothing for the node binary.
+	if len(ids.Driver.ControllerCaps) > 0 {
+		capabilities = append(capabilities,
+			&csi.PluginCapability{
+				Type: &csi.PluginCapability_Service_{
+					Service: &csi.PluginCapability_Service{
+						Type: csi.PluginCapability_Service_CONTROLLER_SERVICE,
+					},
+				},
+			},
+			&csi.PluginCapability{
+				Type: &csi.PluginCapability_Service_{
+					Service: &csi.PluginCapability_Service{
+						Type: csi.PluginCapability_Service_VOLUME_ACCESSIBILITY_CONSTRAINTS,
+					},
+				},
+			},
+		)
+	}
+
+	return &csi.GetPluginCapabilitiesResponse{
+		Capabilities: capabilities,
+	}, nil
+}
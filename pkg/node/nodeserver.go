@@ -0,0 +1,155 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/Ruakij/seaweedfs-csi-driver/pkg/driver"
+	"github.com/chrislusf/seaweedfs/weed/util/log"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type NodeServer struct {
+	Driver *driver.CSIDriver
+}
+
+var _ = csi.NodeServer(&NodeServer{})
+
+func (ns *NodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeStageVolumeRequest) (*csi.NodeStageVolumeResponse, error) {
+	return &csi.NodeStageVolumeResponse{}, nil
+}
+
+func (ns *NodeServer) NodeUnstageVolume(ctx context.Context, req *csi.NodeUnstageVolumeRequest) (*csi.NodeUnstageVolumeResponse, error) {
+	return &csi.NodeUnstageVolumeResponse{}, nil
+}
+
+func (ns *NodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePublishVolumeRequest) (*csi.NodePublishVolumeResponse, error) {
+	volumeId := req.GetVolumeId()
+	targetPath := req.GetTargetPath()
+
+	if volumeId == "" {
+		return nil, status.Error(codes.InvalidArgument, "Volume ID missing in request")
+	}
+	if targetPath == "" {
+		return nil, status.Error(codes.InvalidArgument, "Target path missing in request")
+	}
+
+	if err := os.MkdirAll(targetPath, 0750); err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to create target path %s: %v", targetPath, err)
+	}
+
+	args := []string{"mount", "-filer", ns.Driver.FilerAddress, "-dir", targetPath, "-filer.path", volumeTargetBucketPath(volumeId)}
+	args = append(args, mountFlagsForAccessMode(accessModeFromRequest(req), req.GetReadonly())...)
+	log.Tracef("mounting volume %s at %s: weed %v", volumeId, targetPath, args)
+
+	cmd := exec.Command("weed", args...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, status.Errorf(codes.Internal, "weed mount failed: %v, output: %s", err, out)
+	}
+
+	return &csi.NodePublishVolumeResponse{}, nil
+}
+
+func (ns *NodeServer) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUnpublishVolumeRequest) (*csi.NodeUnpublishVolumeResponse, error) {
+	targetPath := req.GetTargetPath()
+
+	if targetPath == "" {
+		return nil, status.Error(codes.InvalidArgument, "Target path missing in request")
+	}
+
+	cmd := exec.Command("umount", targetPath)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		log.Tracef("umount %s reported: %v, output: %s", targetPath, err, out)
+	}
+
+	if err := os.RemoveAll(targetPath); err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to remove target path %s: %v", targetPath, err)
+	}
+
+	return &csi.NodeUnpublishVolumeResponse{}, nil
+}
+
+func (ns *NodeServer) NodeGetVolumeStats(ctx context.Context, req *csi.NodeGetVolumeStatsRequest) (*csi.NodeGetVolumeStatsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "")
+}
+
+func (ns *NodeServer) NodeExpandVolume(ctx context.Context, req *csi.NodeExpandVolumeRequest) (*csi.NodeExpandVolumeResponse, error) {
+	volumePath := req.GetVolumePath()
+	if volumePath == "" {
+		return nil, status.Error(codes.InvalidArgument, "Volume path missing in request")
+	}
+
+	// SeaweedFS buckets already grow without a hard limit and weed mount has
+	// no quota to reread; there is no local filesystem to grow either, so
+	// this just acknowledges the capacity ControllerExpandVolume recorded.
+	return &csi.NodeExpandVolumeResponse{
+		CapacityBytes: req.GetCapacityRange().GetRequiredBytes(),
+	}, nil
+}
+
+func (ns *NodeServer) NodeGetCapabilities(ctx context.Context, req *csi.NodeGetCapabilitiesRequest) (*csi.NodeGetCapabilitiesResponse, error) {
+	log.Tracef("Using default NodeGetCapabilities")
+
+	return &csi.NodeGetCapabilitiesResponse{
+		Capabilities: ns.Driver.NodeCaps,
+	}, nil
+}
+
+func (ns *NodeServer) NodeGetInfo(ctx context.Context, req *csi.NodeGetInfoRequest) (*csi.NodeGetInfoResponse, error) {
+	resp := &csi.NodeGetInfoResponse{
+		NodeId: ns.Driver.NodeID,
+	}
+
+	segments := make(map[string]string)
+	if ns.Driver.Datacenter != "" {
+		segments[driver.TopologyDatacenterKey] = ns.Driver.Datacenter
+	}
+	if ns.Driver.Rack != "" {
+		segments[driver.TopologyRackKey] = ns.Driver.Rack
+	}
+	if len(segments) > 0 {
+		resp.AccessibleTopology = &csi.Topology{Segments: segments}
+	}
+
+	return resp, nil
+}
+
+func volumeTargetBucketPath(volumeId string) string {
+	return fmt.Sprintf("%s/%s", driver.BucketsDir, volumeId)
+}
+
+// accessModeFromRequest returns the negotiated access mode for a
+// NodePublishVolumeRequest, preferring the VolumeCapability the CO is
+// required to send and falling back to the accessMode CreateVolume stamped
+// onto VolumeContext if a capability is ever missing.
+func accessModeFromRequest(req *csi.NodePublishVolumeRequest) csi.VolumeCapability_AccessMode_Mode {
+	if mode := req.GetVolumeCapability().GetAccessMode().GetMode(); mode != csi.VolumeCapability_AccessMode_UNKNOWN {
+		return mode
+	}
+	if name, ok := req.GetVolumeContext()[driver.AccessModeContextKey]; ok {
+		if value, ok := csi.VolumeCapability_AccessMode_Mode_value[name]; ok {
+			return csi.VolumeCapability_AccessMode_Mode(value)
+		}
+	}
+	return csi.VolumeCapability_AccessMode_UNKNOWN
+}
+
+// mountFlagsForAccessMode translates the negotiated CSI access mode into
+// `weed mount` flags. Readers get -readOnly; multi-writer mounts disable the
+// local write cache so concurrent clients don't see each other's stale data.
+func mountFlagsForAccessMode(mode csi.VolumeCapability_AccessMode_Mode, readonly bool) []string {
+	var flags []string
+
+	if readonly || mode == csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY {
+		flags = append(flags, "-readOnly")
+	}
+	if mode == csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER {
+		flags = append(flags, "-cacheCapacityMB=0")
+	}
+
+	return flags
+}
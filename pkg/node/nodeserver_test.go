@@ -0,0 +1,66 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/Ruakij/seaweedfs-csi-driver/pkg/driver"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+)
+
+func TestMountFlagsForAccessMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     csi.VolumeCapability_AccessMode_Mode
+		readonly bool
+		want     []string
+	}{
+		{"single node writer gets no flags", csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER, false, nil},
+		{"readonly request forces -readOnly", csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER, true, []string{"-readOnly"}},
+		{"multi node reader gets -readOnly", csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY, false, []string{"-readOnly"}},
+		{"multi node multi writer disables the write cache", csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER, false, []string{"-cacheCapacityMB=0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mountFlagsForAccessMode(tt.mode, tt.readonly)
+			if len(got) != len(tt.want) {
+				t.Fatalf("mountFlagsForAccessMode() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("mountFlagsForAccessMode() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestAccessModeFromRequest(t *testing.T) {
+	t.Run("prefers the VolumeCapability on the request", func(t *testing.T) {
+		req := &csi.NodePublishVolumeRequest{
+			VolumeCapability: &csi.VolumeCapability{AccessMode: &csi.VolumeCapability_AccessMode{
+				Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY,
+			}},
+		}
+		if got := accessModeFromRequest(req); got != csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY {
+			t.Errorf("accessModeFromRequest() = %v, want MULTI_NODE_READER_ONLY", got)
+		}
+	})
+
+	t.Run("falls back to the VolumeContext hint", func(t *testing.T) {
+		req := &csi.NodePublishVolumeRequest{
+			VolumeContext: map[string]string{
+				driver.AccessModeContextKey: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER.String(),
+			},
+		}
+		if got := accessModeFromRequest(req); got != csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER {
+			t.Errorf("accessModeFromRequest() = %v, want MULTI_NODE_MULTI_WRITER", got)
+		}
+	})
+
+	t.Run("defaults to UNKNOWN with neither", func(t *testing.T) {
+		if got := accessModeFromRequest(&csi.NodePublishVolumeRequest{}); got != csi.VolumeCapability_AccessMode_UNKNOWN {
+			t.Errorf("accessModeFromRequest() = %v, want UNKNOWN", got)
+		}
+	})
+}